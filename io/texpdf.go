@@ -6,7 +6,13 @@ package io
 
 import (
 	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // FcnConvertNum is a function to convert number to string
@@ -15,13 +21,59 @@ type FcnConvertNum func(row int, x float64) string
 // FcnRow is a function that returns the row value as string
 type FcnRow func(row int) string
 
+// Backend selects how a Report is rendered
+type Backend int
+
+const (
+	// BackendLaTeX renders the report as a .tex file and (optionally) calls pdflatex; this is the default
+	BackendLaTeX Backend = iota
+
+	// BackendNativePDF renders the report directly to PDF without any external toolchain
+	BackendNativePDF
+)
+
+// reportWriter is implemented by each Report backend (LaTeX or native-PDF)
+type reportWriter interface {
+
+	// Section adds a section/subsection heading
+	Section(name string, level int)
+
+	// Table adds a table
+	Table(caption, label string, keys []string, T map[string][]float64, key2tex map[string]string, key2numfmt map[string]FcnConvertNum)
+
+	// LongTable adds a table that may span multiple pages; see Report.AddLongTable
+	LongTable(caption, label string, colAlign []string, keys []string, T map[string][]float64, headerGroups [][]HeaderCell, key2tex map[string]string, key2numfmt map[string]FcnConvertNum, key2cellstyle map[string]func(row int, v float64) CellStyle)
+
+	// Figure adds a figure with a single image
+	Figure(caption, label, imgPath string, opts FigureOpts)
+
+	// Subfigures adds a figure composed of several side-by-side images
+	Subfigures(caption, label string, subs []Subfigure)
+
+	// EnableTOC turns on table-of-contents / outline generation, down to the given section depth
+	EnableTOC(depth int)
+
+	// Anchor marks the current position so it can be the target of a Link
+	Anchor(label string)
+
+	// Link adds clickable text pointing at the position marked by a matching Anchor
+	Link(label, text string)
+
+	// Raw adds backend-specific commands; backends that cannot honour this may ignore it
+	Raw(commands string)
+
+	// Finalize writes the final output file(s) to dirout/fnkey
+	Finalize(dirout, fnkey string) (err error)
+}
+
 // Report holds data to generate LaTeX and PDF files
 type Report struct {
 
 	// configuration
-	Title     string // title of pdf
-	Author    string // author of pdf
-	Landscape bool   // to format paper
+	Title     string  // title of pdf
+	Author    string  // author of pdf
+	Landscape bool    // to format paper
+	Backend   Backend // rendering backend; default is BackendLaTeX
 
 	// default options
 	TablePos    string  // default table positioning key; e.g. !t (to be written as [!t])
@@ -30,41 +82,105 @@ type Report struct {
 	TableColSep float64 // default table column separation in 'em'; e.g. 0.5 => \setlength{\tabcolsep}{0.5em}
 
 	// options
-	DoNotAlignTable   bool // align coluns in TeX table (has to loop over rows first...)
-	DoNotUseGeomPkg   bool // do not use package geometry for margins
-	DoNotGeneratePDF  bool // do not generate pdf when writing tex files
-	DoNotShowMessages bool // do not show messages
+	DoNotAlignTable   bool       // align coluns in TeX table (has to loop over rows first...)
+	DoNotUseGeomPkg   bool       // do not use package geometry for margins
+	DoNotGeneratePDF  bool       // do not generate pdf when writing tex files
+	DoNotShowMessages bool       // do not show messages
+	RowShade          color.RGBA // alternating row background for AddLongTable; zero value disables shading
+
+	// page headers and footers; see SetHeader, SetFooter and PageStyle
+	PageStyle PageStyle // templates for the page header/footer; ignored once SetHeader/SetFooter is used
 
 	// internal
-	buffer *bytes.Buffer
+	buffer        *bytes.Buffer                // tex buffer (BackendLaTeX only)
+	writer        reportWriter                 // active backend; lazily created by wr()
+	headerFn      func(page, total int) string // set by SetHeader; overrides PageStyle.Header
+	footerFn      func(page, total int) string // set by SetFooter; overrides PageStyle.Footer
+	plotTempFiles []string                     // temporary PNGs created by AddPlot; removed once WriteTexPdf has consumed them
+	nativeRawHook NativeRawHook                // set by SetNativeRawHook; used by AddTex on BackendNativePDF
+
+	// reproducibility (see SetDeterministic and CompareRefPDF)
+	deterministic bool
+	seedTime      time.Time
+	lastPdfPath   string // path of the PDF most recently written by WriteTexPdf
+}
+
+// PageSlots holds the left/center/right template strings of a page header or footer line.
+// Templates may use the tokens {page}, {total}, {title} and {date:LAYOUT}, where LAYOUT is a Go
+// reference-time layout (e.g. {date:2006-01-02})
+type PageSlots struct {
+	Left   string
+	Center string
+	Right  string
+}
+
+// empty reports whether all three slots are unset
+func (s PageSlots) empty() bool {
+	return s.Left == "" && s.Center == "" && s.Right == ""
+}
+
+// PageStyle holds the header and footer templates used by Report.PageStyle
+type PageStyle struct {
+	Header PageSlots
+	Footer PageSlots
+}
+
+var reDateToken = regexp.MustCompile(`\{date:([^}]*)\}`)
+
+// expandPageTemplate replaces {title} with title and {date:LAYOUT} with time.Now().Format(LAYOUT)
+// in tmpl, then replaces {page} and {total} with pageTok and totalTok respectively (these are
+// backend-specific: LaTeX macros like \thepage, or a literal number for the native backend)
+func expandPageTemplate(tmpl, title, pageTok, totalTok string) string {
+	if tmpl == "" {
+		return ""
+	}
+	s := reDateToken.ReplaceAllStringFunc(tmpl, func(m string) string {
+		layout := reDateToken.FindStringSubmatch(m)[1]
+		return time.Now().Format(layout)
+	})
+	s = strings.ReplaceAll(s, "{title}", title)
+	s = strings.ReplaceAll(s, "{page}", pageTok)
+	s = strings.ReplaceAll(s, "{total}", totalTok)
+	return s
+}
+
+// wr returns the active backend writer, creating it on first use
+func (o *Report) wr() reportWriter {
+	if o.writer == nil {
+		switch o.Backend {
+		case BackendNativePDF:
+			o.writer = newNativeWriter(o)
+		default:
+			o.writer = newTexWriter(o)
+		}
+	}
+	return o.writer
 }
 
 // Reset clears report
 func (o *Report) Reset() {
-	o.buffer.Reset()
+	o.writer = nil
+	if o.buffer != nil {
+		o.buffer.Reset()
+	}
 }
 
 // AddSection adds section and subsections to report
 func (o *Report) AddSection(name string, level int) {
-	sec := "section"
-	for i := 0; i < level; i++ {
-		if i < 2 {
-			sec = "sub" + sec
-		}
-	}
-	if o.buffer == nil {
-		o.buffer = new(bytes.Buffer)
-	}
-	Ff(o.buffer, "\n")
-	Ff(o.buffer, "\\%s{%s}\n", sec, name)
+	o.wr().Section(name, level)
 }
 
 // AddTex adds TeX commands
+//   NOTE: on BackendNativePDF this is a no-op, unless a hook is set via SetNativeRawHook
 func (o *Report) AddTex(commands string) {
-	if o.buffer == nil {
-		o.buffer = new(bytes.Buffer)
-	}
-	Ff(o.buffer, "\n%s\n", commands)
+	o.wr().Raw(commands)
+}
+
+// SetNativeRawHook sets the passthrough hook AddTex routes through on BackendNativePDF, letting
+// advanced callers escape into raw gofpdf commands where TeX cannot be interpreted. Ignored on
+// BackendLaTeX. Call this before the first AddTex
+func (o *Report) SetNativeRawHook(hook NativeRawHook) {
+	o.nativeRawHook = hook
 }
 
 // AddTable adds tex table to report
@@ -75,165 +191,164 @@ func (o *Report) AddTex(commands string) {
 //   key2tex -- maps key to tex formatted text of this key (i.e. equation). may be nil
 //   key2convert -- maps key to function to convert numbers to string in that column. may be nil
 func (o *Report) AddTable(caption, label string, keys []string, T map[string][]float64, key2tex map[string]string, key2numfmt map[string]FcnConvertNum) {
+	o.wr().Table(caption, label, keys, T, key2tex, key2numfmt)
+}
 
-	// new buffer
-	if o.buffer == nil {
-		o.buffer = new(bytes.Buffer)
-	}
+// HeaderCell is one cell of a (possibly multi-column) AddLongTable header row
+type HeaderCell struct {
+	Text string // header text
+	Span int    // number of columns this header spans; 1 for a normal header cell
+}
 
-	// fix default parameters
-	o.fixDefaults()
+// CellStyle overrides the default rendering of a single AddLongTable cell
+type CellStyle struct {
+	Bold       bool   // render the cell text in bold
+	Color      string // text color, e.g. "#cc0000"; empty keeps the default color
+	Background string // cell background color, e.g. "#ffff99"; empty keeps RowShade/no background
+}
 
-	// find column widths and set formatting string
-	strfmt := make([]string, len(keys)) // for each column
-	if !o.DoNotAlignTable {
-		widths := make([]int, len(keys)) // column widths
-		for j, key := range keys {
-			if key2tex == nil {
-				widths[j] = imax(widths[j], len(key))
-			} else {
-				widths[j] = imax(widths[j], len(key2tex[key]))
-			}
-			for i, v := range T[key] {
-				if key2numfmt == nil {
-					widths[j] = imax(widths[j], len(Sf(o.NumFmt, v)))
-				} else {
-					widths[j] = imax(widths[j], len(Sf(key2numfmt[key](i, v))))
-				}
-			}
-		}
-		for j, width := range widths {
-			strfmt[j] = "%" + Sf("%d", width) + "s"
-		}
-	} else {
-		for j := 0; j < len(keys); j++ {
-			strfmt[j] = "%s"
-		}
-	}
+// AddLongTable adds a table that may span multiple pages, with repeated headers on every page
+//   caption -- caption of table; a "(continued)" suffix is appended on pages after the first
+//   label -- label of table
+//   colAlign -- per-column alignment, one of "l", "c", "r"; nil defaults to "c" for every column
+//   keys -- column keys
+//   T -- table values
+//   headerGroups -- header rows, each a slice of HeaderCell; a cell with Span>1 becomes a
+//     multi-column header. nil falls back to a single header row built from keys/key2tex,
+//     i.e. the same header as AddTable
+//   key2tex -- maps key to tex formatted text of this key (i.e. equation). may be nil
+//   key2numfmt -- maps key to function to convert numbers to string in that column. may be nil
+//   key2cellstyle -- maps key to a function computing a per-cell CellStyle override. may be nil
+func (o *Report) AddLongTable(caption, label string, colAlign []string, keys []string, T map[string][]float64, headerGroups [][]HeaderCell, key2tex map[string]string, key2numfmt map[string]FcnConvertNum, key2cellstyle map[string]func(row int, v float64) CellStyle) {
+	o.wr().LongTable(caption, label, colAlign, keys, T, headerGroups, key2tex, key2numfmt, key2cellstyle)
+}
 
-	// start table
-	Ff(o.buffer, "\n")
-	Ff(o.buffer, "\\begin{table*} [%s] \\centering\n", o.TablePos)
-	Ff(o.buffer, "\\caption{%s}\n", caption)
+// FigureOpts holds optional settings for AddFigure and AddSubfigures
+type FigureOpts struct {
+	Width  string  // width of the image; e.g. "0.8\linewidth" (LaTeX) or "8cm", "80%" (native)
+	Pos    string  // placement key, e.g. "!t", "h"; mirrors Report.TablePos. empty uses TablePos
+	Rotate float64 // rotation angle in degrees, counter-clockwise
+}
 
-	// set fontsize and column separation
-	Ff(o.buffer, o.TableFontSz)
-	Ff(o.buffer, " \\setlength{\\tabcolsep}{%gem}\n", o.TableColSep)
+// Subfigure describes one image within AddSubfigures
+type Subfigure struct {
+	Caption string     // sub-caption
+	Label   string      // sub-label, used to build \label{fig:label}
+	ImgPath string      // path to the image file (JPEG, PNG or GIF)
+	Opts    FigureOpts // placement options for this sub-image
+}
 
-	// start tabular
-	cc := ""
-	for range keys {
-		cc += "c"
-	}
-	Ff(o.buffer, "\\begin{tabular}[c]{%s} \\toprule\n", cc)
+// AddFigure adds a figure with a single image to the report
+//   caption -- caption of figure
+//   label -- label of figure; referenced as \ref{fig:label}
+//   imgPath -- path to a JPEG, PNG or GIF image
+//   opts -- placement options; the zero value uses Report.TablePos and a full-width image
+func (o *Report) AddFigure(caption, label, imgPath string, opts FigureOpts) {
+	o.wr().Figure(caption, label, imgPath, opts)
+}
 
-	// header
-	for j, key := range keys {
-		if j > 0 {
-			Ff(o.buffer, " & ")
-		}
-		if key2tex == nil {
-			Ff(o.buffer, strfmt[j], key)
-		} else {
-			Ff(o.buffer, strfmt[j], key2tex[key])
-		}
-	}
-	Ff(o.buffer, " \\\\ \\hline\n")
+// AddSubfigures adds a figure composed of several side-by-side images to the report
+func (o *Report) AddSubfigures(caption, label string, subs []Subfigure) {
+	o.wr().Subfigures(caption, label, subs)
+}
 
-	// rows
-	nrows := len(T[keys[0]])
-	for i := 0; i < nrows; i++ {
-		if i > 0 {
-			Ff(o.buffer, "\n")
-		}
-		for j, key := range keys {
-			if j > 0 {
-				Ff(o.buffer, " & ")
-			}
-			if key2numfmt == nil {
-				Ff(o.buffer, strfmt[j], Sf(o.NumFmt, T[key][i]))
-			} else {
-				Ff(o.buffer, strfmt[j], key2numfmt[key](i, T[key][i]))
-			}
-		}
-		Ff(o.buffer, " \\\\")
-	}
+// EnableTOC turns on table-of-contents generation; depth controls how many section levels are
+// listed (0 = sections only, 1 = sections and subsections, ...)
+//   NOTE: on BackendLaTeX, \tableofcontents is resolved from the .aux file across the whole
+//   document regardless of when EnableTOC is called. On BackendNativePDF, the PDF outline is
+//   built incrementally as AddSection runs -- sections added before EnableTOC are NOT added to
+//   the outline retroactively. Call this before the first AddSection to get the same TOC on both
+//   backends
+func (o *Report) EnableTOC(depth int) {
+	o.wr().EnableTOC(depth)
+}
 
-	// end tabular and table
-	Ff(o.buffer, "\n")
-	Ff(o.buffer, "\\bottomrule\n")
-	Ff(o.buffer, "\\end{tabular}\n")
-	Ff(o.buffer, "\\label{tab:%s}\n", label)
-	Ff(o.buffer, "\\end{table*}")
+// AddAnchor marks the current position in the report with label, so it can later be the target
+// of AddLink, RefFigure or RefTable
+func (o *Report) AddAnchor(label string) {
+	o.wr().Anchor(label)
 }
 
-// WriteTexPdf writes tex file and generates pdf file
-//  extra -- extra LaTeX commands; may be nil
-func (o *Report) WriteTexPdf(dirout, fnkey string, extra *bytes.Buffer) (err error) {
+// AddLink adds clickable text pointing at the position marked by AddAnchor(label)
+func (o *Report) AddLink(label, text string) {
+	o.wr().Link(label, text)
+}
 
-	// header
-	pdf := new(bytes.Buffer)
-	if o.Landscape {
-		Ff(pdf, "\\documentclass[a4paper,landscape]{article}\n")
-	} else {
-		Ff(pdf, "\\documentclass[a4paper]{article}\n")
-	}
-	Ff(pdf, "\\usepackage{amsmath}\n")
-	Ff(pdf, "\\usepackage{amssymb}\n")
-	Ff(pdf, "\\usepackage{booktabs}\n")
-	if !o.DoNotUseGeomPkg {
-		Ff(pdf, "\\usepackage[margin=1.5cm,footskip=0.5cm]{geometry}\n")
-	}
+// SetHeader sets a callback used to render the page header, overriding PageStyle.Header.
+//   fn receives the current page number and total page count and returns the header text.
+//   NOTE: on BackendLaTeX, fn is called once (with page=-1, total=-1) and its result is emitted
+//   verbatim as TeX via fancyhdr's \fancyhead[C]; use PageStyle.Header instead if you need the
+//   {page}/{total} tokens to resolve per page. On BackendNativePDF, fn is invoked once per page
+//   with the true page number; total is not yet known while pages are being laid out and is
+//   passed as -1 -- use the {total} token in PageStyle.Footer/Header for an accurate count
+//   (resolved via gofpdf's page-count alias when the document is closed)
+//   Call this before adding any content
+func (o *Report) SetHeader(fn func(page, total int) string) {
+	o.headerFn = fn
+}
 
-	// title and author
-	hasTitleOrAuthor := false
-	if o.Title != "" {
-		Ff(pdf, "\n")
-		Ff(pdf, "\\title{%s}\n", o.Title)
-		hasTitleOrAuthor = true
-	}
-	if o.Author != "" {
-		Ff(pdf, "\\author{%s}\n", o.Author)
-		hasTitleOrAuthor = true
-	}
+// SetFooter sets a callback used to render the page footer, overriding PageStyle.Footer. See the
+// caveats documented on SetHeader; call this before adding any content
+func (o *Report) SetFooter(fn func(page, total int) string) {
+	o.footerFn = fn
+}
+
+// RefFigure returns a cross-reference to a figure added via AddFigure/AddSubfigures
+func (o *Report) RefFigure(label string) string {
+	return o.ref("fig", label)
+}
 
-	// begin document
-	Ff(pdf, "\n")
-	Ff(pdf, "\\begin{document}\n")
-	if hasTitleOrAuthor {
-		Ff(pdf, "\\maketitle\n")
+// RefTable returns a cross-reference to a table added via AddTable
+func (o *Report) RefTable(label string) string {
+	return o.ref("tab", label)
+}
+
+// ref builds a cross-reference string for the active backend
+//   on BackendNativePDF the result is an anchor key usable with AddLink, e.g. AddLink(o.RefFigure("x"), "see figure")
+//   AddFigure and AddTable register a matching anchor automatically on that backend
+func (o *Report) ref(kind, label string) string {
+	if o.Backend == BackendNativePDF {
+		return kind + ":" + label
 	}
+	return "\\ref{" + kind + ":" + label + "}"
+}
 
-	// buffer
-	if o.buffer != nil {
-		Ff(pdf, "%v\n", o.buffer)
+// removePlotTempFiles deletes the temporary PNGs created by AddPlot, once the active backend has
+// read them into the generated PDF; errors are ignored since the files live in os.TempDir()
+func (o *Report) removePlotTempFiles() {
+	for _, fn := range o.plotTempFiles {
+		os.Remove(fn)
 	}
+	o.plotTempFiles = nil
+}
 
-	// extra LaTeX commands
+// WriteTexPdf writes tex file and generates pdf file (or writes pdf file directly on BackendNativePDF)
+//  extra -- extra LaTeX commands; may be nil. passed to AddTex, so on BackendNativePDF it is
+//  ignored unless a hook is set via SetNativeRawHook
+//  NOTE: if the GOSL_REPORT_REF environment variable is set, the generated PDF is compared
+//  against the file at that path (see CompareRefPDF) and a non-nil error is returned on mismatch
+func (o *Report) WriteTexPdf(dirout, fnkey string, extra *bytes.Buffer) (err error) {
 	if extra != nil {
-		Ff(pdf, "\n%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%% extra commands %%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%%\n\n")
-		Ff(pdf, "%v\n", extra)
+		o.AddTex(extra.String())
 	}
-
-	// end document
-	Ff(pdf, "\n")
-	Ff(pdf, "\\end{document}\n")
-
-	// write TeX file
-	fn := fnkey + ".tex"
-	WriteFileD(dirout, fn, pdf)
-
-	// run pdflatex
-	if !o.DoNotGeneratePDF {
-		_, err = RunCmd(false, "pdflatex", "-interaction=batchmode", "-halt-on-error", "-output-directory="+dirout, fn)
+	if err = o.wr().Finalize(dirout, fnkey); err != nil {
+		return
+	}
+	if o.Backend == BackendLaTeX && o.DoNotGeneratePDF {
+		// the .tex file still references the AddPlot temp files for a later manual pdflatex run
+		return
+	}
+	o.removePlotTempFiles()
+	o.lastPdfPath = filepath.Join(dirout, fnkey+".pdf")
+	if ref := os.Getenv("GOSL_REPORT_REF"); ref != "" {
+		var diff []PageDiff
+		diff, err = o.CompareRefPDF(ref)
 		if err != nil {
-			if !o.DoNotShowMessages {
-				PfRed("file <%s/%s> generated\n", dirout, fn)
-			}
 			return
 		}
-		if !o.DoNotShowMessages {
-			PfBlue("file <%s/%s.pdf> generated\n", dirout, fnkey)
+		if len(diff) > 0 {
+			err = fmt.Errorf("WriteTexPdf: generated PDF does not match GOSL_REPORT_REF=%s (%d difference(s); first: object %d key %q: expected %q, got %q)",
+				ref, len(diff), diff[0].ObjectID, diff[0].Key, diff[0].Expected, diff[0].Actual)
 		}
 	}
 	return
@@ -263,6 +378,36 @@ func (o *Report) fixDefaults() {
 	}
 }
 
+// tableColWidths computes the rendered width of each column, honouring DoNotAlignTable
+func (o *Report) tableColWidths(keys []string, T map[string][]float64, key2tex map[string]string, key2numfmt map[string]FcnConvertNum) (strfmt []string) {
+	strfmt = make([]string, len(keys))
+	if !o.DoNotAlignTable {
+		widths := make([]int, len(keys))
+		for j, key := range keys {
+			if key2tex == nil {
+				widths[j] = imax(widths[j], len(key))
+			} else {
+				widths[j] = imax(widths[j], len(key2tex[key]))
+			}
+			for i, v := range T[key] {
+				if key2numfmt == nil {
+					widths[j] = imax(widths[j], len(Sf(o.NumFmt, v)))
+				} else {
+					widths[j] = imax(widths[j], len(Sf(key2numfmt[key](i, v))))
+				}
+			}
+		}
+		for j, width := range widths {
+			strfmt[j] = "%" + Sf("%d", width) + "s"
+		}
+	} else {
+		for j := range keys {
+			strfmt[j] = "%s"
+		}
+	}
+	return
+}
+
 // TexNum returns a string representation in TeX format of a real number.
 // scientificNotation:
 //   peforms the conversion of numbers into scientific notation where
@@ -0,0 +1,144 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PageDiff describes one difference found by CompareRefPDF/ComparePDFs
+type PageDiff struct {
+	Page     int    // page index (1-based) if the differing object is a /Type /Page object; -1 otherwise
+	ObjectID int    // PDF object id
+	Key      string // the dictionary key that differs; "" for a missing/extra object
+	Expected string // value found in the reference PDF
+	Actual   string // value found in the generated PDF
+}
+
+// DefaultCompareSkipKeys are dictionary keys that legitimately vary between two otherwise
+// identical PDF runs (timestamps, random IDs) and are skipped by CompareRefPDF unless the caller
+// passes its own key list to ComparePDFs
+var DefaultCompareSkipKeys = []string{"/CreationDate", "/ModDate", "/ID"}
+
+// pdfDate formats t as a PDF date string, e.g. 20260725120000
+func pdfDate(t time.Time) string {
+	return t.Format("20060102150405")
+}
+
+// SetDeterministic fixes the PDF creation/modification dates to seedTime and disables stream
+// compression, so that two runs of the same report produce byte-for-byte comparable PDFs (modulo
+// the keys in DefaultCompareSkipKeys). Call this before WriteTexPdf
+func (o *Report) SetDeterministic(seedTime time.Time) {
+	o.deterministic = true
+	o.seedTime = seedTime
+}
+
+// CompareRefPDF compares the PDF most recently written by WriteTexPdf against refPath and returns
+// a structured diff; an empty, non-nil diff slice means the files matched modulo
+// DefaultCompareSkipKeys
+func (o *Report) CompareRefPDF(refPath string) (diff []PageDiff, err error) {
+	if o.lastPdfPath == "" {
+		return nil, fmt.Errorf("CompareRefPDF: WriteTexPdf must be called before comparing")
+	}
+	return ComparePDFs(o.lastPdfPath, refPath, DefaultCompareSkipKeys)
+}
+
+// ComparePDFs tokenizes two PDFs into their object streams (mirroring the technique used by
+// gofpdf's own compare.go) and returns the dictionary entries that differ, skipping skipKeys
+//   pathA -- the generated PDF; its values are reported as PageDiff.Actual
+//   pathB -- the reference PDF; its values are reported as PageDiff.Expected
+func ComparePDFs(pathA, pathB string, skipKeys []string) (diff []PageDiff, err error) {
+	dataA, err := os.ReadFile(pathA)
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := os.ReadFile(pathB)
+	if err != nil {
+		return nil, err
+	}
+	objsA := parsePDFObjects(dataA)
+	objsB := parsePDFObjects(dataB)
+
+	diff = []PageDiff{}
+	for id, a := range objsA {
+		b, ok := objsB[id]
+		if !ok {
+			diff = append(diff, PageDiff{Page: a.page, ObjectID: id, Expected: "(missing)", Actual: "(present)"})
+			continue
+		}
+		for key, av := range a.dict {
+			if skipKey(key, skipKeys) {
+				continue
+			}
+			bv, ok := b.dict[key]
+			if !ok || bv != av {
+				diff = append(diff, PageDiff{Page: a.page, ObjectID: id, Key: key, Expected: bv, Actual: av})
+			}
+		}
+		for key := range b.dict {
+			if skipKey(key, skipKeys) {
+				continue
+			}
+			if _, ok := a.dict[key]; !ok {
+				diff = append(diff, PageDiff{Page: a.page, ObjectID: id, Key: key, Expected: b.dict[key], Actual: ""})
+			}
+		}
+	}
+	for id, b := range objsB {
+		if _, ok := objsA[id]; !ok {
+			diff = append(diff, PageDiff{Page: b.page, ObjectID: id, Expected: "(present)", Actual: "(missing)"})
+		}
+	}
+	return diff, nil
+}
+
+// skipKey reports whether key is in the skip list
+func skipKey(key string, skipKeys []string) bool {
+	for _, k := range skipKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfObject is one "N G obj << ... >> endobj" entry of a PDF file
+type pdfObject struct {
+	dict map[string]string // dictionary key (e.g. "/Type") to its raw value
+	page int               // 1-based page index if this is a /Type /Page object; -1 otherwise
+}
+
+var (
+	reObj  = regexp.MustCompile(`(?s)(\d+)\s+\d+\s+obj\s*(.*?)endobj`)
+	reKV   = regexp.MustCompile(`/([A-Za-z0-9_]+)\s*((?:\([^)]*\))|(?:<[^>]*>)|(?:\[[^\]]*\])|(?:/?[^/<>\[\]()\s]+))`)
+	rePage = regexp.MustCompile(`/Type\s*/Page\b`)
+)
+
+// parsePDFObjects extracts a best-effort dictionary per PDF object; this is not a full PDF
+// parser (it does not decode compressed streams or cross-reference tables), but is enough to
+// compare two uncompressed, deterministic PDFs produced by Report.WriteTexPdf
+func parsePDFObjects(data []byte) map[int]pdfObject {
+	out := make(map[int]pdfObject)
+	pageNo := 0
+	for _, m := range reObj.FindAllStringSubmatch(string(data), -1) {
+		var id int
+		fmt.Sscanf(m[1], "%d", &id)
+		body := m[2]
+		o := pdfObject{dict: make(map[string]string), page: -1}
+		for _, kv := range reKV.FindAllStringSubmatch(body, -1) {
+			o.dict[strings.TrimSpace("/"+kv[1])] = strings.TrimSpace(kv[2])
+		}
+		if rePage.MatchString(body) {
+			pageNo++
+			o.page = pageNo
+		}
+		out[id] = o
+	}
+	return out
+}
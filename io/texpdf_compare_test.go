@@ -0,0 +1,73 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParsePDFObjects checks that object dictionaries and page numbers are extracted from a
+// minimal, uncompressed PDF object stream
+func TestParsePDFObjects(tst *testing.T) {
+	data := []byte(`1 0 obj << /Type /Page /Count 3 >> endobj
+2 0 obj << /Type /Font /BaseFont /Helvetica >> endobj`)
+	objs := parsePDFObjects(data)
+	if len(objs) != 2 {
+		tst.Fatalf("parsePDFObjects: got %d objects; want 2", len(objs))
+	}
+	if objs[1].dict["/Count"] != "3" || objs[1].page != 1 {
+		tst.Errorf("parsePDFObjects: object 1 = %+v; want /Count=3, page=1", objs[1])
+	}
+	if objs[2].dict["/BaseFont"] != "/Helvetica" || objs[2].page != -1 {
+		tst.Errorf("parsePDFObjects: object 2 = %+v; want /BaseFont=/Helvetica, page=-1", objs[2])
+	}
+}
+
+// TestComparePDFs checks that a common-key mismatch, a generated-only object and a reference-only
+// object are all reported with pathA (generated) as Actual and pathB (reference) as Expected
+func TestComparePDFs(tst *testing.T) {
+	dir := tst.TempDir()
+	pathA := filepath.Join(dir, "generated.pdf")
+	pathB := filepath.Join(dir, "reference.pdf")
+	dataA := `1 0 obj << /Type /Page /Count 3 >> endobj
+2 0 obj << /Type /Font /BaseFont /Helvetica >> endobj`
+	dataB := `1 0 obj << /Type /Page /Count 5 >> endobj
+3 0 obj << /Type /Pages >> endobj`
+	if err := os.WriteFile(pathA, []byte(dataA), 0644); err != nil {
+		tst.Fatal(err)
+	}
+	if err := os.WriteFile(pathB, []byte(dataB), 0644); err != nil {
+		tst.Fatal(err)
+	}
+
+	diff, err := ComparePDFs(pathA, pathB, nil)
+	if err != nil {
+		tst.Fatal(err)
+	}
+
+	find := func(id int, key string) (PageDiff, bool) {
+		for _, d := range diff {
+			if d.ObjectID == id && d.Key == key {
+				return d, true
+			}
+		}
+		return PageDiff{}, false
+	}
+
+	d, ok := find(1, "/Count")
+	if !ok || d.Expected != "5" || d.Actual != "3" {
+		tst.Errorf("ComparePDFs: object 1 /Count diff = %+v, found=%v; want Expected=5, Actual=3", d, ok)
+	}
+	d, ok = find(2, "")
+	if !ok || d.Expected != "(missing)" || d.Actual != "(present)" {
+		tst.Errorf("ComparePDFs: object 2 diff = %+v, found=%v; want Expected=(missing), Actual=(present)", d, ok)
+	}
+	d, ok = find(3, "")
+	if !ok || d.Expected != "(present)" || d.Actual != "(missing)" {
+		tst.Errorf("ComparePDFs: object 3 diff = %+v, found=%v; want Expected=(present), Actual=(missing)", d, ok)
+	}
+}
@@ -0,0 +1,543 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// NativeRawHook, when set, lets AddTex escape into backend-specific PDF commands on
+// BackendNativePDF instead of being silently ignored; it receives the raw text passed to AddTex
+// and the underlying *gofpdf.Fpdf so advanced users can draw directly
+type NativeRawHook func(pdf *gofpdf.Fpdf, commands string)
+
+// nativeWriter implements reportWriter by drawing directly to a PDF with gofpdf; no external
+// toolchain (e.g. pdflatex) is required
+type nativeWriter struct {
+	rep        *Report
+	pdf        *gofpdf.Fpdf
+	nsec       int           // number of sections added so far
+	RawHook    NativeRawHook // optional escape hatch for AddTex; see NativeRawHook
+	tocEnabled bool
+	tocDepth   int
+	linkIDs    map[string]int // anchor/link label -> gofpdf internal link id
+}
+
+// newNativeWriter creates a new nativeWriter tied to rep
+func newNativeWriter(rep *Report) *nativeWriter {
+	o := &nativeWriter{rep: rep, RawHook: rep.nativeRawHook}
+	orientation := "P"
+	if rep.Landscape {
+		orientation = "L"
+	}
+	o.pdf = gofpdf.New(orientation, "cm", "A4", "")
+	if !rep.DoNotUseGeomPkg {
+		o.pdf.SetMargins(1.5, 1.5, 1.5)
+		o.pdf.SetAutoPageBreak(true, 0.5)
+	}
+	if rep.deterministic {
+		o.pdf.SetCreationDate(rep.seedTime)
+		o.pdf.SetModificationDate(rep.seedTime)
+		o.pdf.SetCompression(false)
+	}
+	o.setupPageStyle()
+	o.pdf.AddPage()
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.addTitlePage()
+	return o
+}
+
+// setupPageStyle registers gofpdf header/footer callbacks for Report.PageStyle/SetHeader/SetFooter;
+// must run before the first AddPage so it applies to every page, including the first
+func (o *nativeWriter) setupPageStyle() {
+	rep := o.rep
+	hasHeader := rep.headerFn != nil || !rep.PageStyle.Header.empty()
+	hasFooter := rep.footerFn != nil || !rep.PageStyle.Footer.empty()
+	if !hasHeader && !hasFooter {
+		return
+	}
+	o.pdf.AliasNbPages("") // enables the "{nb}" token, substituted with the true page count at Close()
+	if hasHeader {
+		o.pdf.SetHeaderFunc(func() { o.drawPageBand(true) })
+	}
+	if hasFooter {
+		o.pdf.SetFooterFunc(func() { o.drawPageBand(false) })
+	}
+}
+
+// drawPageBand draws the header or footer line for the current page
+func (o *nativeWriter) drawPageBand(isHeader bool) {
+	rep := o.rep
+	page := o.pdf.PageNo()
+	fn := rep.footerFn
+	slots := rep.PageStyle.Footer
+	if isHeader {
+		fn = rep.headerFn
+		slots = rep.PageStyle.Header
+	}
+
+	o.pdf.SetFont("Helvetica", "", 9)
+	if isHeader {
+		o.pdf.SetY(0.6)
+	} else {
+		o.pdf.SetY(-1.2)
+	}
+
+	if fn != nil {
+		txt := fn(page, -1)
+		o.pdf.CellFormat(0, 0.5, txt, "", 0, "C", false, 0, "")
+	} else {
+		pageW, _, _ := o.pdf.PageSize(0)
+		marginL, _, marginR, _ := o.pdf.GetMargins()
+		w := (pageW - marginL - marginR) / 3
+		pageTok := Sf("%d", page)
+		o.pdf.CellFormat(w, 0.5, expandPageTemplate(slots.Left, rep.Title, pageTok, "{nb}"), "", 0, "L", false, 0, "")
+		o.pdf.CellFormat(w, 0.5, expandPageTemplate(slots.Center, rep.Title, pageTok, "{nb}"), "", 0, "C", false, 0, "")
+		o.pdf.CellFormat(w, 0.5, expandPageTemplate(slots.Right, rep.Title, pageTok, "{nb}"), "", 0, "R", false, 0, "")
+	}
+	o.pdf.SetFont("Helvetica", "", 11)
+}
+
+// addTitlePage draws the title/author, mirroring the LaTeX backend's \maketitle
+func (o *nativeWriter) addTitlePage() {
+	if o.rep.Title == "" && o.rep.Author == "" {
+		return
+	}
+	if o.rep.Title != "" {
+		o.pdf.SetFont("Helvetica", "B", 18)
+		o.pdf.CellFormat(0, 1, o.rep.Title, "", 1, "C", false, 0, "")
+	}
+	if o.rep.Author != "" {
+		o.pdf.SetFont("Helvetica", "", 12)
+		o.pdf.CellFormat(0, 0.8, o.rep.Author, "", 1, "C", false, 0, "")
+	}
+	o.pdf.Ln(0.5)
+	o.pdf.SetFont("Helvetica", "", 11)
+}
+
+// Section adds a section or subsection title
+func (o *nativeWriter) Section(name string, level int) {
+	sz := 16.0 - 2.0*float64(imin(level, 2))
+	o.pdf.Ln(0.3)
+	o.pdf.SetFont("Helvetica", "B", sz)
+	o.pdf.CellFormat(0, 0.8, name, "", 1, "L", false, 0, "")
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.nsec++
+	if o.tocEnabled && level <= o.tocDepth {
+		o.pdf.Bookmark(name, level, -1)
+	}
+}
+
+// EnableTOC turns on PDF outline/bookmark generation, limited to the given section depth
+//   NOTE: the outline is built incrementally as Section runs, so this must be called before the
+//   first AddSection -- sections added beforehand are not bookmarked retroactively; see
+//   Report.EnableTOC
+func (o *nativeWriter) EnableTOC(depth int) {
+	o.tocEnabled = true
+	o.tocDepth = depth
+}
+
+// linkID returns the gofpdf internal link id for label, creating one on first use
+func (o *nativeWriter) linkID(label string) int {
+	if o.linkIDs == nil {
+		o.linkIDs = make(map[string]int)
+	}
+	if id, ok := o.linkIDs[label]; ok {
+		return id
+	}
+	id := o.pdf.AddLink()
+	o.linkIDs[label] = id
+	return id
+}
+
+// Anchor marks the current position as the target of a later Link
+func (o *nativeWriter) Anchor(label string) {
+	o.pdf.SetLink(o.linkID(label), o.pdf.GetY(), -1)
+}
+
+// Link adds clickable text pointing at a matching Anchor
+func (o *nativeWriter) Link(label, text string) {
+	x, y := o.pdf.GetXY()
+	w := o.pdf.GetStringWidth(text)
+	o.pdf.SetTextColor(0, 0, 238)
+	o.pdf.CellFormat(w, 0.5, text, "", 0, "L", false, 0, "")
+	o.pdf.SetTextColor(0, 0, 0)
+	o.pdf.Link(x, y, w, 0.5, o.linkID(label))
+}
+
+// Raw routes backend-specific commands through RawHook, if set; otherwise it is a no-op since
+// arbitrary TeX cannot be interpreted by the native backend
+func (o *nativeWriter) Raw(commands string) {
+	hook := o.RawHook
+	if hook == nil {
+		hook = o.rep.nativeRawHook // set by SetNativeRawHook after this writer was created
+	}
+	if hook != nil {
+		hook(o.pdf, commands)
+	}
+}
+
+// texNumToUTF8 translates the output of TexNum's scientific notation (e.g. "1.2\cdot 10^{-3}")
+// into a plain-text rendering using the unicode superscript digits, since the native backend
+// has no TeX typesetting engine to interpret \cdot and ^{}
+func texNumToUTF8(s string) string {
+	const marker = "\\cdot 10^{"
+	i := strings.Index(s, marker)
+	if i < 0 {
+		return s
+	}
+	mantissa := s[:i]
+	rest := s[i+len(marker):]
+	j := strings.Index(rest, "}")
+	if j < 0 {
+		return s
+	}
+	exp := rest[:j]
+	var sup strings.Builder
+	for _, c := range exp {
+		switch c {
+		case '-':
+			sup.WriteRune('⁻')
+		case '0':
+			sup.WriteRune('⁰')
+		case '1':
+			sup.WriteRune('¹')
+		case '2':
+			sup.WriteRune('²')
+		case '3':
+			sup.WriteRune('³')
+		default:
+			if c >= '4' && c <= '9' {
+				sup.WriteRune(rune('⁴' + (c - '4')))
+			} else {
+				sup.WriteRune(c)
+			}
+		}
+	}
+	return mantissa + "×10" + sup.String()
+}
+
+// Table draws a table, honouring TableColSep, TableFontSz and the same column auto-width
+// computation used by the LaTeX backend
+func (o *nativeWriter) Table(caption, label string, keys []string, T map[string][]float64, key2tex map[string]string, key2numfmt map[string]FcnConvertNum) {
+
+	// fix default parameters
+	o.rep.fixDefaults()
+	strfmt := o.rep.tableColWidths(keys, T, key2tex, key2numfmt)
+
+	// caption
+	o.pdf.Ln(0.3)
+	o.pdf.SetFont("Helvetica", "B", 11)
+	o.pdf.CellFormat(0, 0.6, caption, "", 1, "L", false, 0, "")
+
+	// font size for the table body; TableFontSz carries a TeX size name (e.g. \scriptsize)
+	fontsz := nativeFontSize(o.rep.TableFontSz)
+	o.pdf.SetFont("Helvetica", "", fontsz)
+
+	// column width in cm, from the character widths computed above plus TableColSep padding
+	pageW, _, _ := o.pdf.PageSize(0)
+	marginL, _, marginR, _ := o.pdf.GetMargins()
+	avail := pageW - marginL - marginR
+	chars := make([]float64, len(keys))
+	total := 0.0
+	for j := range keys {
+		n, _ := strconv.Atoi(strings.Trim(strfmt[j], "%s"))
+		chars[j] = float64(n) + o.rep.TableColSep
+		total += chars[j]
+	}
+	colw := make([]float64, len(keys))
+	for j := range keys {
+		if total > 0 {
+			colw[j] = avail * chars[j] / total
+		} else {
+			colw[j] = avail / float64(len(keys))
+		}
+	}
+
+	// header
+	for j, key := range keys {
+		txt := key
+		if key2tex != nil {
+			txt = key2tex[key]
+		}
+		o.pdf.CellFormat(colw[j], 0.6, txt, "B", 0, "C", false, 0, "")
+	}
+	o.pdf.Ln(-1)
+
+	// rows
+	nrows := len(T[keys[0]])
+	for i := 0; i < nrows; i++ {
+		for j, key := range keys {
+			var txt string
+			if key2numfmt == nil {
+				txt = texNumToUTF8(Sf(o.rep.NumFmt, T[key][i]))
+			} else {
+				txt = texNumToUTF8(key2numfmt[key](i, T[key][i]))
+			}
+			o.pdf.CellFormat(colw[j], 0.55, txt, "", 0, "C", false, 0, "")
+		}
+		o.pdf.Ln(-1)
+	}
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.Anchor("tab:" + label)
+}
+
+// Figure adds a figure with a single image
+func (o *nativeWriter) Figure(caption, label, imgPath string, opts FigureOpts) {
+	pageW, _, _ := o.pdf.PageSize(0)
+	marginL, _, marginR, _ := o.pdf.GetMargins()
+	avail := pageW - marginL - marginR
+	w := nativeImgWidth(opts.Width, avail)
+	x := marginL + (avail-w)/2
+	y := o.pdf.GetY()
+	if opts.Rotate != 0 {
+		o.pdf.TransformBegin()
+		o.pdf.TransformRotate(opts.Rotate, x, y)
+	}
+	o.pdf.ImageOptions(imgPath, x, y, w, 0, true, gofpdf.ImageOptions{ImageType: "", ReadDpi: true}, 0, "")
+	if opts.Rotate != 0 {
+		o.pdf.TransformEnd()
+	}
+	o.pdf.SetFont("Helvetica", "", 10)
+	o.pdf.CellFormat(0, 0.5, caption, "", 1, "C", false, 0, "")
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.Anchor("fig:" + label)
+}
+
+// Subfigures adds a figure composed of several side-by-side images
+func (o *nativeWriter) Subfigures(caption, label string, subs []Subfigure) {
+	pageW, _, _ := o.pdf.PageSize(0)
+	marginL, _, marginR, _ := o.pdf.GetMargins()
+	avail := pageW - marginL - marginR
+	n := imax(1, len(subs))
+	w := avail / float64(n)
+	y := o.pdf.GetY()
+	for i, sub := range subs {
+		x := marginL + float64(i)*w
+		iw := nativeImgWidth(sub.Opts.Width, w*0.9)
+		ix := x + (w-iw)/2
+		if sub.Opts.Rotate != 0 {
+			o.pdf.TransformBegin()
+			o.pdf.TransformRotate(sub.Opts.Rotate, ix, y)
+		}
+		o.pdf.ImageOptions(sub.ImgPath, ix, y, iw, 0, true, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		if sub.Opts.Rotate != 0 {
+			o.pdf.TransformEnd()
+		}
+	}
+	o.pdf.SetY(y + avail/float64(n)) // advance roughly one row height; exact aspect ratio is per-image
+	o.pdf.SetFont("Helvetica", "", 10)
+	o.pdf.CellFormat(0, 0.5, caption, "", 1, "C", false, 0, "")
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.Anchor("fig:" + label)
+}
+
+// nativeImgWidth resolves a FigureOpts.Width spec ("8cm", "80%", or empty) into centimetres
+func nativeImgWidth(spec string, avail float64) float64 {
+	if spec == "" {
+		return avail
+	}
+	if strings.HasSuffix(spec, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil {
+			return avail
+		}
+		return avail * pct / 100
+	}
+	if strings.HasSuffix(spec, "cm") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(spec, "cm"), 64)
+		if err == nil {
+			return v
+		}
+	}
+	return avail
+}
+
+// LongTable draws a table that may span multiple pages, manually repeating the header whenever
+// a page break is needed; colAlign governs the alignment of each data cell, mirroring the LaTeX
+// backend's tabular column spec
+func (o *nativeWriter) LongTable(caption, label string, colAlign []string, keys []string, T map[string][]float64, headerGroups [][]HeaderCell, key2tex map[string]string, key2numfmt map[string]FcnConvertNum, key2cellstyle map[string]func(row int, v float64) CellStyle) {
+
+	o.rep.fixDefaults()
+
+	align := colAlign
+	if align == nil {
+		align = make([]string, len(keys))
+		for j := range align {
+			align[j] = "c"
+		}
+	}
+
+	pageW, pageH, _ := o.pdf.PageSize(0)
+	marginL, marginT, marginR, marginB := o.pdf.GetMargins()
+	_ = marginT
+	avail := pageW - marginL - marginR
+	colw := make([]float64, len(keys))
+	for j := range keys {
+		colw[j] = avail / float64(len(keys))
+	}
+
+	groups := headerGroups
+	if groups == nil {
+		row := make([]HeaderCell, len(keys))
+		for j, key := range keys {
+			txt := key
+			if key2tex != nil {
+				txt = key2tex[key]
+			}
+			row[j] = HeaderCell{Text: txt, Span: 1}
+		}
+		groups = [][]HeaderCell{row}
+	}
+
+	fontsz := nativeFontSize(o.rep.TableFontSz)
+	drawHeader := func(cap string) {
+		o.pdf.SetFont("Helvetica", "B", 11)
+		o.pdf.CellFormat(0, 0.6, cap, "", 1, "L", false, 0, "")
+		o.pdf.SetFont("Helvetica", "B", fontsz)
+		for _, row := range groups {
+			j := 0
+			for _, cell := range row {
+				w := 0.0
+				for s := 0; s < cell.Span && j+s < len(colw); s++ {
+					w += colw[j+s]
+				}
+				o.pdf.CellFormat(w, 0.6, cell.Text, "B", 0, "C", false, 0, "")
+				j += cell.Span
+			}
+			o.pdf.Ln(-1)
+		}
+		o.pdf.SetFont("Helvetica", "", fontsz)
+	}
+
+	drawHeader(caption)
+
+	nrows := len(T[keys[0]])
+	for i := 0; i < nrows; i++ {
+		if o.pdf.GetY()+0.6 > pageH-marginB {
+			o.pdf.AddPage()
+			drawHeader(caption + " (continued)")
+		}
+		rowShaded := o.rep.RowShade != (color.RGBA{}) && i%2 == 1
+		if rowShaded {
+			x, y := o.pdf.GetXY()
+			o.pdf.SetFillColor(int(o.rep.RowShade.R), int(o.rep.RowShade.G), int(o.rep.RowShade.B))
+			o.pdf.Rect(x, y, avail, 0.55, "F")
+		}
+		for j, key := range keys {
+			var txt string
+			if key2numfmt == nil {
+				txt = texNumToUTF8(Sf(o.rep.NumFmt, T[key][i]))
+			} else {
+				txt = texNumToUTF8(key2numfmt[key](i, T[key][i]))
+			}
+			style := ""
+			fill := false
+			if key2cellstyle != nil {
+				if cs := key2cellstyle[key]; cs != nil {
+					st := cs(i, T[key][i])
+					if st.Bold {
+						o.pdf.SetFont("Helvetica", "B", fontsz)
+						style = "B"
+					}
+					if st.Color != "" {
+						r, g, b := hexToRGB(st.Color)
+						o.pdf.SetTextColor(r, g, b)
+					}
+					if st.Background != "" {
+						r, g, b := hexToRGB(st.Background)
+						o.pdf.SetFillColor(r, g, b)
+						fill = true
+					}
+				}
+			}
+			o.pdf.CellFormat(colw[j], 0.55, txt, "", 0, nativeCellAlign(align[j]), fill, 0, "")
+			if style != "" {
+				o.pdf.SetFont("Helvetica", "", fontsz)
+			}
+			if fill && rowShaded {
+				o.pdf.SetFillColor(int(o.rep.RowShade.R), int(o.rep.RowShade.G), int(o.rep.RowShade.B))
+			}
+			o.pdf.SetTextColor(0, 0, 0)
+		}
+		o.pdf.Ln(-1)
+	}
+	o.pdf.SetFont("Helvetica", "", 11)
+	o.Anchor("tab:" + label)
+}
+
+// hexToRGB parses a "#rrggbb" string into separate int components; invalid input returns black
+func hexToRGB(s string) (r, g, b int) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0
+	}
+	var rr, gg, bb int
+	if _, err := fmt.Sscanf(s[1:], "%02x%02x%02x", &rr, &gg, &bb); err != nil {
+		return 0, 0, 0
+	}
+	return rr, gg, bb
+}
+
+// nativeCellAlign translates a colAlign spec ("l", "c" or "r") into gofpdf's CellFormat align
+// argument; anything else defaults to centred, same as the LaTeX backend's "c" default
+func nativeCellAlign(spec string) string {
+	switch spec {
+	case "l":
+		return "L"
+	case "r":
+		return "R"
+	default:
+		return "C"
+	}
+}
+
+// nativeFontSize converts a TeX font-size command (e.g. \scriptsize) into a point size usable
+// by gofpdf; unknown/empty values fall back to the normal body size
+func nativeFontSize(texCmd string) float64 {
+	switch texCmd {
+	case `\tiny`:
+		return 6
+	case `\scriptsize`:
+		return 8
+	case `\footnotesize`:
+		return 9
+	case `\small`:
+		return 10
+	case `\large`:
+		return 13
+	case `\Large`:
+		return 15
+	default:
+		return 11
+	}
+}
+
+// Finalize writes the PDF file directly; no external toolchain is invoked
+func (o *nativeWriter) Finalize(dirout, fnkey string) (err error) {
+	fn := fnkey + ".pdf"
+	if o.rep.deterministic {
+		// SetDeterministic may have been called after this writer was created
+		o.pdf.SetCreationDate(o.rep.seedTime)
+		o.pdf.SetModificationDate(o.rep.seedTime)
+		o.pdf.SetCompression(false)
+	}
+	err = o.pdf.OutputFileAndClose(filepath.Join(dirout, fn))
+	if err != nil {
+		if !o.rep.DoNotShowMessages {
+			PfRed("file <%s/%s> generated\n", dirout, fn)
+		}
+		return
+	}
+	if !o.rep.DoNotShowMessages {
+		PfBlue("file <%s/%s> generated\n", dirout, fn)
+	}
+	return
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import "testing"
+
+// TestTexNumToUTF8 checks that scientific-notation TeX fragments are translated into their
+// plain-text, unicode-superscript equivalent
+func TestTexNumToUTF8(tst *testing.T) {
+	tests := []struct{ in, out string }{
+		{"1.2\\cdot 10^{-3}", "1.2×10⁻³"},
+		{"5\\cdot 10^{12}", "5×10¹²"},
+		{"3.14", "3.14"},            // no scientific notation marker: passed through unchanged
+		{"1\\cdot 10^{0}", "1×10⁰"}, // single-digit exponent
+	}
+	for _, t := range tests {
+		if res := texNumToUTF8(t.in); res != t.out {
+			tst.Errorf("texNumToUTF8(%q) = %q; want %q", t.in, res, t.out)
+		}
+	}
+}
+
+// TestNativeImgWidth checks that a FigureOpts.Width spec ("", "80%", "8cm" or anything else) is
+// resolved into centimetres on the native backend
+func TestNativeImgWidth(tst *testing.T) {
+	tests := []struct {
+		spec  string
+		avail float64
+		want  float64
+	}{
+		{"", 16.0, 16.0},      // empty spec: full available width
+		{"80%", 16.0, 12.8},   // percentage spec
+		{"8cm", 16.0, 8.0},    // absolute spec
+		{"bogus", 16.0, 16.0}, // unrecognized spec: falls back to avail
+	}
+	for _, t := range tests {
+		if res := nativeImgWidth(t.spec, t.avail); res != t.want {
+			tst.Errorf("nativeImgWidth(%q, %g) = %g; want %g", t.spec, t.avail, res, t.want)
+		}
+	}
+}
+
+// TestHexToRGB checks that "#rrggbb" CellStyle.Color/Background specs are parsed into RGB
+// components on the native backend, and that empty or invalid specs fall back to black
+func TestHexToRGB(tst *testing.T) {
+	tests := []struct {
+		spec    string
+		r, g, b int
+	}{
+		{"#ff0000", 255, 0, 0},
+		{"#00ff00", 0, 255, 0},
+		{"#0000ff", 0, 0, 255},
+		{"", 0, 0, 0},            // empty: defaults to black
+		{"not-a-color", 0, 0, 0}, // invalid: defaults to black
+	}
+	for _, t := range tests {
+		r, g, b := hexToRGB(t.spec)
+		if r != t.r || g != t.g || b != t.b {
+			tst.Errorf("hexToRGB(%q) = (%d,%d,%d); want (%d,%d,%d)", t.spec, r, g, b, t.r, t.g, t.b)
+		}
+	}
+}
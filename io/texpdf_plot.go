@@ -0,0 +1,167 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+)
+
+// PlotStyle holds the (minimal) styling options for AddPlot
+type PlotStyle struct {
+	Color  string  // line/marker color; e.g. "#0000ff". empty defaults to black
+	Marker bool    // draw a small square marker at each point
+	Line   bool    // draw straight segments connecting consecutive points; default true if Marker is false
+	Width  float64 // plot image width in pixels; 0 defaults to 640
+	Height float64 // plot image height in pixels; 0 defaults to 480
+}
+
+// AddPlot renders a minimal line/scatter plot of (xs, ys) to a temporary PNG file and embeds it
+// via AddFigure, so numerical results from other gosl packages can be dropped straight into a
+// report without leaving Go; the temporary file is removed by WriteTexPdf once it has been
+// consumed by the active backend
+func (o *Report) AddPlot(caption, label string, xs, ys []float64, style PlotStyle) (err error) {
+	fn, err := renderPlotPNG(xs, ys, style)
+	if err != nil {
+		return err
+	}
+	o.plotTempFiles = append(o.plotTempFiles, fn)
+	o.AddFigure(caption, label, fn, FigureOpts{})
+	return nil
+}
+
+// renderPlotPNG draws xs-vs-ys to a temporary PNG file and returns its path
+func renderPlotPNG(xs, ys []float64, style PlotStyle) (fn string, err error) {
+	w, h := int(style.Width), int(style.Height)
+	if w <= 0 {
+		w = 640
+	}
+	if h <= 0 {
+		h = 480
+	}
+	if len(xs) != len(ys) || len(xs) == 0 {
+		return "", fmt.Errorf("AddPlot: xs and ys must have the same non-zero length (%d != %d)", len(xs), len(ys))
+	}
+
+	// data bounding box
+	xmin, xmax := xs[0], xs[0]
+	ymin, ymax := ys[0], ys[0]
+	for i := range xs {
+		xmin, xmax = math.Min(xmin, xs[i]), math.Max(xmax, xs[i])
+		ymin, ymax = math.Min(ymin, ys[i]), math.Max(ymax, ys[i])
+	}
+	if xmax == xmin {
+		xmax = xmin + 1
+	}
+	if ymax == ymin {
+		ymax = ymin + 1
+	}
+
+	// margins, in pixels, to leave room for axes
+	const margin = 40
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	white := color.RGBA{255, 255, 255, 255}
+	axis := color.RGBA{0, 0, 0, 255}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, white)
+		}
+	}
+	drawLine(img, margin, h-margin, w-margin, h-margin, axis) // x-axis
+	drawLine(img, margin, margin, margin, h-margin, axis)     // y-axis
+
+	// map data coordinates to pixel coordinates
+	toPx := func(x, y float64) (int, int) {
+		px := margin + int((x-xmin)/(xmax-xmin)*float64(w-2*margin))
+		py := (h - margin) - int((y-ymin)/(ymax-ymin)*float64(h-2*margin))
+		return px, py
+	}
+	col := parsePlotColor(style.Color)
+	line := style.Line || !style.Marker
+	var prevX, prevY int
+	for i := range xs {
+		px, py := toPx(xs[i], ys[i])
+		if style.Marker {
+			drawMarker(img, px, py, col)
+		}
+		if line && i > 0 {
+			drawLine(img, prevX, prevY, px, py, col)
+		}
+		prevX, prevY = px, py
+	}
+
+	// write to a temporary file
+	f, err := os.CreateTemp("", "gosl-plot-*.png")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err = png.Encode(f, img); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// parsePlotColor converts a "#rrggbb" string into a color.RGBA; empty or invalid input is black
+func parsePlotColor(s string) color.RGBA {
+	if len(s) == 7 && s[0] == '#' {
+		var r, g, b int
+		if _, err := fmt.Sscanf(s[1:], "%02x%02x%02x", &r, &g, &b); err == nil {
+			return color.RGBA{uint8(r), uint8(g), uint8(b), 255}
+		}
+	}
+	return color.RGBA{0, 0, 0, 255}
+}
+
+// drawLine draws a straight line using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+	dx := iabs(x1 - x0)
+	dy := -iabs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	errv := dx + dy
+	for {
+		img.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			break
+		}
+		e2 := 2 * errv
+		if e2 >= dy {
+			errv += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			errv += dx
+			y0 += sy
+		}
+	}
+}
+
+// drawMarker draws a small filled square centred on (cx, cy)
+func drawMarker(img *image.RGBA, cx, cy int, col color.RGBA) {
+	const r = 2
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			img.Set(x, y, col)
+		}
+	}
+}
+
+// iabs returns the absolute value of an integer
+func iabs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,416 @@
+// Copyright 2016 The Gosl Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package io
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+)
+
+// texWriter implements reportWriter by emitting LaTeX into Report.buffer; this is the original
+// (and default) Report backend
+type texWriter struct {
+	rep           *Report
+	tocEnabled    bool
+	tocDepth      int
+	usesHyperref  bool
+	usesXcolor    bool
+	usesLongtable bool
+}
+
+// newTexWriter creates a new texWriter tied to rep
+func newTexWriter(rep *Report) *texWriter {
+	return &texWriter{rep: rep}
+}
+
+// buf returns the report's tex buffer, creating it on first use
+func (o *texWriter) buf() *bytes.Buffer {
+	if o.rep.buffer == nil {
+		o.rep.buffer = new(bytes.Buffer)
+	}
+	return o.rep.buffer
+}
+
+// Section adds section and subsections to report
+func (o *texWriter) Section(name string, level int) {
+	sec := "section"
+	for i := 0; i < level; i++ {
+		if i < 2 {
+			sec = "sub" + sec
+		}
+	}
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\%s{%s}\n", sec, name)
+}
+
+// Raw adds TeX commands
+func (o *texWriter) Raw(commands string) {
+	Ff(o.buf(), "\n%s\n", commands)
+}
+
+// Table adds tex table to report
+func (o *texWriter) Table(caption, label string, keys []string, T map[string][]float64, key2tex map[string]string, key2numfmt map[string]FcnConvertNum) {
+
+	// fix default parameters
+	o.rep.fixDefaults()
+
+	// find column widths and set formatting string
+	strfmt := o.rep.tableColWidths(keys, T, key2tex, key2numfmt)
+
+	// start table
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\begin{table*} [%s] \\centering\n", o.rep.TablePos)
+	Ff(o.buf(), "\\caption{%s}\n", caption)
+
+	// set fontsize and column separation
+	Ff(o.buf(), o.rep.TableFontSz)
+	Ff(o.buf(), " \\setlength{\\tabcolsep}{%gem}\n", o.rep.TableColSep)
+
+	// start tabular
+	cc := ""
+	for range keys {
+		cc += "c"
+	}
+	Ff(o.buf(), "\\begin{tabular}[c]{%s} \\toprule\n", cc)
+
+	// header
+	for j, key := range keys {
+		if j > 0 {
+			Ff(o.buf(), " & ")
+		}
+		if key2tex == nil {
+			Ff(o.buf(), strfmt[j], key)
+		} else {
+			Ff(o.buf(), strfmt[j], key2tex[key])
+		}
+	}
+	Ff(o.buf(), " \\\\ \\hline\n")
+
+	// rows
+	nrows := len(T[keys[0]])
+	for i := 0; i < nrows; i++ {
+		if i > 0 {
+			Ff(o.buf(), "\n")
+		}
+		for j, key := range keys {
+			if j > 0 {
+				Ff(o.buf(), " & ")
+			}
+			if key2numfmt == nil {
+				Ff(o.buf(), strfmt[j], Sf(o.rep.NumFmt, T[key][i]))
+			} else {
+				Ff(o.buf(), strfmt[j], key2numfmt[key](i, T[key][i]))
+			}
+		}
+		Ff(o.buf(), " \\\\")
+	}
+
+	// end tabular and table
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\bottomrule\n")
+	Ff(o.buf(), "\\end{tabular}\n")
+	Ff(o.buf(), "\\label{tab:%s}\n", label)
+	Ff(o.buf(), "\\end{table*}")
+}
+
+// EnableTOC turns on \tableofcontents, limited to the given section depth
+func (o *texWriter) EnableTOC(depth int) {
+	o.tocEnabled = true
+	o.tocDepth = depth
+}
+
+// Anchor marks the current position as the target of a later Link, via \hypertarget
+func (o *texWriter) Anchor(label string) {
+	o.usesHyperref = true
+	Ff(o.buf(), "\\hypertarget{%s}{}\n", label)
+}
+
+// Link adds clickable text pointing at a matching Anchor, via \hyperlink
+func (o *texWriter) Link(label, text string) {
+	o.usesHyperref = true
+	Ff(o.buf(), "\\hyperlink{%s}{%s}", label, text)
+}
+
+// LongTable adds a table that may span multiple pages, via the longtable package
+func (o *texWriter) LongTable(caption, label string, colAlign []string, keys []string, T map[string][]float64, headerGroups [][]HeaderCell, key2tex map[string]string, key2numfmt map[string]FcnConvertNum, key2cellstyle map[string]func(row int, v float64) CellStyle) {
+
+	o.rep.fixDefaults()
+	o.usesLongtable = true
+
+	align := colAlign
+	if align == nil {
+		align = make([]string, len(keys))
+		for j := range align {
+			align[j] = "c"
+		}
+	}
+	groups := headerGroups
+	if groups == nil {
+		row := make([]HeaderCell, len(keys))
+		for j, key := range keys {
+			txt := key
+			if key2tex != nil {
+				txt = key2tex[key]
+			}
+			row[j] = HeaderCell{Text: txt, Span: 1}
+		}
+		groups = [][]HeaderCell{row}
+	}
+
+	usesShade := o.rep.RowShade != (color.RGBA{})
+	usesCellColor := key2cellstyle != nil
+	if usesShade || usesCellColor {
+		o.usesXcolor = true
+	}
+
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\begin{longtable}{%s}\n", strings.Join(align, "|"))
+	Ff(o.buf(), "\\caption{%s} \\label{tab:%s} \\\\ \\toprule\n", caption, label)
+	o.emitHeaderGroups(groups)
+	Ff(o.buf(), "\\midrule\n\\endfirsthead\n")
+	Ff(o.buf(), "\\multicolumn{%d}{c}{\\small %s (continued)} \\\\ \\toprule\n", len(keys), caption)
+	o.emitHeaderGroups(groups)
+	Ff(o.buf(), "\\midrule\n\\endhead\n")
+	Ff(o.buf(), "\\midrule \\multicolumn{%d}{r}{\\small continued on next page} \\\\ \\endfoot\n", len(keys))
+	Ff(o.buf(), "\\bottomrule\n\\endlastfoot\n")
+
+	nrows := len(T[keys[0]])
+	for i := 0; i < nrows; i++ {
+		if usesShade && i%2 == 1 {
+			Ff(o.buf(), "\\rowcolor[RGB]{%d,%d,%d}\n", o.rep.RowShade.R, o.rep.RowShade.G, o.rep.RowShade.B)
+		}
+		for j, key := range keys {
+			if j > 0 {
+				Ff(o.buf(), " & ")
+			}
+			var txt string
+			if key2numfmt == nil {
+				txt = Sf(o.rep.NumFmt, T[key][i])
+			} else {
+				txt = key2numfmt[key](i, T[key][i])
+			}
+			if key2cellstyle != nil {
+				txt = applyCellStyle(txt, key2cellstyle[key], i, T[key][i])
+			}
+			Ff(o.buf(), "%s", txt)
+		}
+		Ff(o.buf(), " \\\\\n")
+	}
+	Ff(o.buf(), "\\end{longtable}\n")
+}
+
+// emitHeaderGroups writes one or more header rows, honouring multi-column spans
+func (o *texWriter) emitHeaderGroups(groups [][]HeaderCell) {
+	for _, row := range groups {
+		for j, cell := range row {
+			if j > 0 {
+				Ff(o.buf(), " & ")
+			}
+			if cell.Span > 1 {
+				Ff(o.buf(), "\\multicolumn{%d}{c}{%s}", cell.Span, cell.Text)
+			} else {
+				Ff(o.buf(), "%s", cell.Text)
+			}
+		}
+		Ff(o.buf(), " \\\\\n")
+	}
+}
+
+// applyCellStyle wraps txt in \textbf / \textcolor according to the CellStyle returned by fcn,
+// if any
+func applyCellStyle(txt string, fcn func(row int, v float64) CellStyle, row int, v float64) string {
+	if fcn == nil {
+		return txt
+	}
+	st := fcn(row, v)
+	if st.Bold {
+		txt = "\\textbf{" + txt + "}"
+	}
+	if st.Color != "" {
+		txt = "\\textcolor[HTML]{" + strings.TrimPrefix(st.Color, "#") + "}{" + txt + "}"
+	}
+	if st.Background != "" {
+		txt = "\\cellcolor[HTML]{" + strings.TrimPrefix(st.Background, "#") + "}" + txt
+	}
+	return txt
+}
+
+// Figure adds a figure with a single image
+func (o *texWriter) Figure(caption, label, imgPath string, opts FigureOpts) {
+	pos := opts.Pos
+	if pos == "" {
+		pos = o.rep.TablePos
+	}
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\begin{figure} [%s] \\centering\n", pos)
+	Ff(o.buf(), "%s", o.includegraphics(imgPath, opts))
+	Ff(o.buf(), "\\caption{%s}\n", caption)
+	Ff(o.buf(), "\\label{fig:%s}\n", label)
+	Ff(o.buf(), "\\end{figure}\n")
+}
+
+// Subfigures adds a figure composed of several side-by-side images
+func (o *texWriter) Subfigures(caption, label string, subs []Subfigure) {
+	pos := o.rep.TablePos
+	Ff(o.buf(), "\n")
+	Ff(o.buf(), "\\begin{figure} [%s] \\centering\n", pos)
+	for _, sub := range subs {
+		Ff(o.buf(), "\\begin{subfigure}{0.45\\linewidth} \\centering\n")
+		Ff(o.buf(), "%s", o.includegraphics(sub.ImgPath, sub.Opts))
+		Ff(o.buf(), "\\caption{%s}\n", sub.Caption)
+		Ff(o.buf(), "\\label{fig:%s}\n", sub.Label)
+		Ff(o.buf(), "\\end{subfigure}\n")
+	}
+	Ff(o.buf(), "\\caption{%s}\n", caption)
+	Ff(o.buf(), "\\label{fig:%s}\n", label)
+	Ff(o.buf(), "\\end{figure}\n")
+}
+
+// includegraphics builds a \includegraphics command honouring FigureOpts
+func (o *texWriter) includegraphics(imgPath string, opts FigureOpts) string {
+	keys := ""
+	if opts.Width != "" {
+		keys += "width=" + opts.Width
+	} else {
+		keys += "width=\\linewidth"
+	}
+	if opts.Rotate != 0 {
+		keys += Sf(",angle=%g", opts.Rotate)
+	}
+	return Sf("\\includegraphics[%s]{%s}\n", keys, imgPath)
+}
+
+// Finalize writes tex file and generates pdf file
+func (o *texWriter) Finalize(dirout, fnkey string) (err error) {
+
+	// header
+	pdf := new(bytes.Buffer)
+	if o.rep.Landscape {
+		Ff(pdf, "\\documentclass[a4paper,landscape]{article}\n")
+	} else {
+		Ff(pdf, "\\documentclass[a4paper]{article}\n")
+	}
+	Ff(pdf, "\\usepackage{amsmath}\n")
+	Ff(pdf, "\\usepackage{amssymb}\n")
+	Ff(pdf, "\\usepackage{booktabs}\n")
+	Ff(pdf, "\\usepackage{graphicx}\n")   // \includegraphics (Figure, Subfigures)
+	Ff(pdf, "\\usepackage{subcaption}\n") // subfigure environment (Subfigures)
+	if o.usesLongtable {
+		Ff(pdf, "\\usepackage{longtable}\n")
+	}
+	if o.usesXcolor {
+		Ff(pdf, "\\usepackage[table]{xcolor}\n")
+	}
+	if !o.rep.DoNotUseGeomPkg {
+		Ff(pdf, "\\usepackage[margin=1.5cm,footskip=0.5cm]{geometry}\n")
+	}
+	if o.usesHyperref {
+		Ff(pdf, "\\usepackage{hyperref}\n")
+	}
+	if o.tocEnabled {
+		Ff(pdf, "\\setcounter{tocdepth}{%d}\n", o.tocDepth+1) // LaTeX numbers \section as level 1
+	}
+	if o.rep.deterministic {
+		Ff(pdf, "\\pdfcompresslevel=0\n")
+		Ff(pdf, "\\pdfobjcompresslevel=0\n")
+		Ff(pdf, "\\pdfinfo{ /CreationDate (D:%s) /ModDate (D:%s) }\n", pdfDate(o.rep.seedTime), pdfDate(o.rep.seedTime))
+	}
+	o.writeHeaderFooterPreamble(pdf)
+
+	// title and author
+	hasTitleOrAuthor := false
+	if o.rep.Title != "" {
+		Ff(pdf, "\n")
+		Ff(pdf, "\\title{%s}\n", o.rep.Title)
+		hasTitleOrAuthor = true
+	}
+	if o.rep.Author != "" {
+		Ff(pdf, "\\author{%s}\n", o.rep.Author)
+		hasTitleOrAuthor = true
+	}
+
+	// begin document
+	Ff(pdf, "\n")
+	Ff(pdf, "\\begin{document}\n")
+	if hasTitleOrAuthor {
+		Ff(pdf, "\\maketitle\n")
+	}
+	if o.tocEnabled {
+		Ff(pdf, "\\tableofcontents\n")
+	}
+
+	// buffer
+	if o.rep.buffer != nil {
+		Ff(pdf, "%v\n", o.rep.buffer)
+	}
+
+	// end document
+	Ff(pdf, "\n")
+	Ff(pdf, "\\end{document}\n")
+
+	// write TeX file
+	fn := fnkey + ".tex"
+	WriteFileD(dirout, fn, pdf)
+
+	// run pdflatex
+	if !o.rep.DoNotGeneratePDF {
+		_, err = RunCmd(false, "pdflatex", "-interaction=batchmode", "-halt-on-error", "-output-directory="+dirout, fn)
+		if err != nil {
+			if !o.rep.DoNotShowMessages {
+				PfRed("file <%s/%s> generated\n", dirout, fn)
+			}
+			return
+		}
+		if !o.rep.DoNotShowMessages {
+			PfBlue("file <%s/%s.pdf> generated\n", dirout, fnkey)
+		}
+	}
+	return
+}
+
+// writeHeaderFooterPreamble emits the fancyhdr setup for Report.PageStyle/SetHeader/SetFooter
+func (o *texWriter) writeHeaderFooterPreamble(pdf *bytes.Buffer) {
+	hasHeader := o.rep.headerFn != nil || !o.rep.PageStyle.Header.empty()
+	hasFooter := o.rep.footerFn != nil || !o.rep.PageStyle.Footer.empty()
+	if !hasHeader && !hasFooter {
+		return
+	}
+	usesTotal := strings.Contains(o.rep.PageStyle.Header.Left+o.rep.PageStyle.Header.Center+o.rep.PageStyle.Header.Right+
+		o.rep.PageStyle.Footer.Left+o.rep.PageStyle.Footer.Center+o.rep.PageStyle.Footer.Right, "{total}")
+	Ff(pdf, "\\usepackage{fancyhdr}\n")
+	if usesTotal {
+		Ff(pdf, "\\usepackage{lastpage}\n")
+	}
+	Ff(pdf, "\\pagestyle{fancy}\n\\fancyhf{}\n")
+	if o.rep.headerFn != nil {
+		Ff(pdf, "\\fancyhead[C]{%s}\n", o.rep.headerFn(-1, -1))
+	} else if hasHeader {
+		o.writeFancySlots(pdf, "head", o.rep.PageStyle.Header)
+	}
+	if o.rep.footerFn != nil {
+		Ff(pdf, "\\fancyfoot[C]{%s}\n", o.rep.footerFn(-1, -1))
+	} else if hasFooter {
+		o.writeFancySlots(pdf, "foot", o.rep.PageStyle.Footer)
+	}
+}
+
+// writeFancySlots emits \fancyhead/\fancyfoot[L/C/R]{...} for each non-empty slot
+func (o *texWriter) writeFancySlots(pdf *bytes.Buffer, kind string, slots PageSlots) {
+	if slots.Left != "" {
+		Ff(pdf, "\\fancy%s[L]{%s}\n", kind, o.texTemplate(slots.Left))
+	}
+	if slots.Center != "" {
+		Ff(pdf, "\\fancy%s[C]{%s}\n", kind, o.texTemplate(slots.Center))
+	}
+	if slots.Right != "" {
+		Ff(pdf, "\\fancy%s[R]{%s}\n", kind, o.texTemplate(slots.Right))
+	}
+}
+
+// texTemplate expands {page}, {total}, {title} and {date:LAYOUT} into TeX/literal text
+func (o *texWriter) texTemplate(tmpl string) string {
+	return expandPageTemplate(tmpl, o.rep.Title, "\\thepage", "\\pageref{LastPage}")
+}